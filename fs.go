@@ -0,0 +1,46 @@
+package gosu
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// FileSystem abstracts the filesystem operations Glob needs, so it can
+// run over trees other than the OS filesystem.
+type FileSystem interface {
+	Open(name string) (File, error)
+	Stat(name string) (os.FileInfo, error)
+	Lstat(name string) (os.FileInfo, error)
+	ReadDir(name string) ([]os.FileInfo, error)
+}
+
+// File is the subset of *os.File operations FileSystem.Open exposes.
+type File interface {
+	io.Reader
+	io.Closer
+	Stat() (os.FileInfo, error)
+}
+
+// OSFileSystem is the default FileSystem, backed by the local disk.
+var OSFileSystem FileSystem = osFileSystem{}
+
+// osFileSystem implements FileSystem over the local disk using the
+// standard library.
+type osFileSystem struct{}
+
+func (osFileSystem) Open(name string) (File, error) {
+	return os.Open(name)
+}
+
+func (osFileSystem) Stat(name string) (os.FileInfo, error) {
+	return os.Stat(name)
+}
+
+func (osFileSystem) Lstat(name string) (os.FileInfo, error) {
+	return os.Lstat(name)
+}
+
+func (osFileSystem) ReadDir(name string) ([]os.FileInfo, error) {
+	return ioutil.ReadDir(name)
+}
@@ -1,122 +1,124 @@
 package gosu
 
 import (
-	"bytes"
 	"os"
-	"regexp"
+	"path/filepath"
 	"strings"
 	"sync"
-	"unicode/utf8"
 
 	"github.com/MichaelTJones/walk"
+	"github.com/mgutz/gosu/glob"
 	"github.com/mgutz/gosu/util"
 )
 
-const (
-	// NotSlash is any rune but path separator.
-	notSlash = "[^/]"
-	// AnyRune is zero or more non-path separators.
-	anyRune = notSlash + "*"
-	// ZeroOrMoreDirectories is used by ** patterns.
-	zeroOrMoreDirectories = "((?:[\\w\\.\\-]+\\/)*)"
-	// TrailingStarStar matches everything inside directory.
-	trailingStarStar = "/**"
-	// SlashStarStarSlash maches zero or more directories.
-	slashStarStarSlash = "/**/"
-)
+// SkipDir is returned by a GlobWalk callback to skip the directory (or, if
+// the callback was invoked for a file, the rest of that file's siblings)
+// currently being visited, without aborting the rest of the walk. It is
+// the same sentinel value recognized by filepath.Walk.
+var SkipDir = filepath.SkipDir
 
-// RegexpInfo contains additional info about the Regexp created by a glob pattern.
-type RegexpInfo struct {
-	*regexp.Regexp
-	Negate bool
+// GlobOptions holds the optional configuration accepted by GlobWith and
+// GlobWalk.
+type GlobOptions struct {
+	fs              FileSystem
+	caseInsensitive bool
+	matchHidden     bool
 }
 
-// Globexp builds a regular express from from extended glob pattern and then
-// returns a Regexp object from the pattern.
-func Globexp(glob string) *regexp.Regexp {
-	var re bytes.Buffer
-
-	re.WriteString("^")
-
-	i, inGroup := 0, false
-	for i < len(glob) {
-		r, w := utf8.DecodeRuneInString(glob[i:])
-
-		switch r {
-		default:
-			re.WriteRune(r)
-
-		case '\\', '$', '^', '+', '.', '(', ')', '=', '!', '|':
-			re.WriteRune('\\')
-			re.WriteRune(r)
-
-		case '/':
-			// TODO optimize later, string could be long
-			rest := glob[i:]
-			re.WriteRune('/')
-			if strings.HasPrefix(rest, "/**/") {
-				re.WriteString(zeroOrMoreDirectories)
-				w *= 4
-			} else if rest == "/**" {
-				re.WriteString(".*")
-				w *= 3
-			}
-
-		case '?':
-			re.WriteRune('.')
+// GlobOption configures optional Glob/GlobWalk behavior.
+type GlobOption func(*GlobOptions)
 
-		case '[', ']':
-			re.WriteRune(r)
+// WithFS makes GlobWith/GlobWalk operate over fs instead of the OS
+// filesystem.
+func WithFS(fs FileSystem) GlobOption {
+	return func(o *GlobOptions) { o.fs = fs }
+}
 
-		case '{':
-			inGroup = true
-			re.WriteRune('(')
+// CaseInsensitive makes patterns match regardless of letter case. The
+// pattern's leading wildcard-free directories, which decide where the walk
+// starts and which directories GlobWalk prunes, are still compared with
+// their case as written.
+func CaseInsensitive() GlobOption {
+	return func(o *GlobOptions) { o.caseInsensitive = true }
+}
 
-		case '}':
-			inGroup = false
-			re.WriteRune(')')
+// MatchHidden controls whether dot-prefixed path segments (like .git) are
+// visited while walking. It defaults to true; pass false so that, for
+// example, "**/*.go" doesn't dive into .git.
+func MatchHidden(match bool) GlobOption {
+	return func(o *GlobOptions) { o.matchHidden = match }
+}
 
-		case ',':
-			if inGroup {
-				re.WriteRune('|')
-			} else {
-				re.WriteRune('\\')
-				re.WriteRune(r)
-			}
+// newGlobOptions returns the default GlobOptions with opts applied.
+func newGlobOptions(opts []GlobOption) *GlobOptions {
+	o := &GlobOptions{fs: OSFileSystem, matchHidden: true}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
 
-		case '*':
-			rest := glob[i:]
-			if strings.HasPrefix(rest, "**/") {
-				re.WriteString(zeroOrMoreDirectories)
-				w *= 3
-			} else {
-				re.WriteString(anyRune)
-			}
-		}
+// compilePattern compiles pattern, honoring o.caseInsensitive.
+func compilePattern(pattern string, o *GlobOptions) (glob.Pattern, error) {
+	if o.caseInsensitive {
+		return glob.Compile(pattern, glob.CaseInsensitive())
+	}
+	return Globexp(pattern)
+}
 
-		i += w
+// isHidden reports whether the last path segment of path starts with a
+// dot, the usual convention for hidden files and directories.
+func isHidden(path string) bool {
+	base := path
+	if i := strings.LastIndex(path, "/"); i >= 0 {
+		base = path[i+1:]
 	}
+	return strings.HasPrefix(base, ".") && base != "." && base != ".."
+}
 
-	re.WriteString("$")
-	//log.Printf("regex string %s", re.String())
-	return regexp.MustCompile(re.String())
+// RegexpInfo contains additional info about the Pattern created by a glob pattern.
+type RegexpInfo struct {
+	glob.Pattern
+	Negate bool
 }
 
-// Glob returns files and dirctories that match patterns.
+// Globexp compiles pattern using the doublestar dialect implemented by the
+// gosu/glob package and returns the resulting Pattern.
+func Globexp(pattern string) (glob.Pattern, error) {
+	return glob.Compile(pattern)
+}
+
+// Glob returns files and directories under the OS filesystem that match
+// patterns. See GlobFS and GlobWith for variants that glob over a
+// different FileSystem or accept other GlobOptions.
 //
 // Special chars.
 //
-//   /**/   - match zero or more directories
-//   {a,b}  - match a or b, no spaces
-//   *      - match any non-separator char
-//   ?      - match a single non-separator char
-//   **/    - match any directory, start of pattern only
-//   /**    - match any this directory, end of pattern only
-//   !      - removes files from resultset, start of pattern only
-//
+//	**     - match zero or more directories, anywhere in the pattern
+//	{a,b}  - match a or b, groups may nest, no spaces
+//	[a-z]  - match a POSIX character class
+//	[!a-z] - match the negation of a POSIX character class
+//	*      - match any non-separator chars
+//	?      - match a single non-separator char
+//	\x     - match x literally, escaping any of the above
+//	!      - removes files from resultset, start of pattern only
 func Glob(patterns []string) ([]*FileAsset, []*RegexpInfo, error) {
+	return GlobWith(patterns)
+}
+
+// GlobFS behaves like Glob but walks fs instead of the OS filesystem. This
+// is what makes Glob testable without touching disk, and lets callers
+// glob over in-memory trees, archives, or virtual overlays.
+func GlobFS(fs FileSystem, patterns []string) ([]*FileAsset, []*RegexpInfo, error) {
+	return GlobWith(patterns, WithFS(fs))
+}
+
+// GlobWith behaves like Glob but accepts GlobOptions such as WithFS,
+// CaseInsensitive and MatchHidden.
+func GlobWith(patterns []string, opts ...GlobOption) ([]*FileAsset, []*RegexpInfo, error) {
 	// TODO very inefficient and unintelligent, optimize later
 
+	o := newGlobOptions(opts)
 	m := map[string]*FileAsset{}
 	regexps := []*RegexpInfo{}
 
@@ -124,27 +126,33 @@ func Glob(patterns []string) ([]*FileAsset, []*RegexpInfo, error) {
 		remove := strings.HasPrefix(pattern, "!")
 		if remove {
 			pattern = pattern[1:]
-			re := Globexp(pattern)
-			regexps = append(regexps, &RegexpInfo{Regexp: re, Negate: true})
+		}
+
+		pat, err := compilePattern(pattern, o)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if remove {
+			regexps = append(regexps, &RegexpInfo{Pattern: pat, Negate: true})
 			for path := range m {
-				if re.MatchString(path) {
+				if pat.Match(path) {
 					m[path] = nil
 				}
 			}
 		} else {
-			re := Globexp(pattern)
-			regexps = append(regexps, &RegexpInfo{Regexp: re})
+			regexps = append(regexps, &RegexpInfo{Pattern: pat})
 			root := patternRoot(pattern)
 			if root == "" {
 				util.Panic("glob", "Cannot get root from pattern: %s", pattern)
 			}
-			fileAssets, err := walkFiles(root)
+			fileAssets, err := walkFiles(o.fs, root, o.matchHidden)
 			if err != nil {
 				return nil, nil, err
 			}
 
 			for _, file := range fileAssets {
-				if re.MatchString(file.Path) {
+				if pat.Match(file.Path) {
 					// TODO closure problem assigning &file
 					tmp := file
 					tmp.PatternRoot = root
@@ -165,6 +173,91 @@ func Glob(patterns []string) ([]*FileAsset, []*RegexpInfo, error) {
 	return keys, regexps, nil
 }
 
+// GlobWalk compiles patterns once, walks the filesystem a single time, and
+// invokes fn for every entry whose path matches an include pattern and no
+// exclude pattern. It avoids the intermediate slice Glob builds, so it's
+// the better choice over large trees. It walks the OS filesystem unless
+// passed WithFS. Walking the OS filesystem uses the concurrent
+// github.com/MichaelTJones/walk walker, but GlobWalk serializes calls into
+// fn, so fn itself never needs to guard against concurrent invocation.
+//
+// Whole subtrees are pruned as soon as no include pattern could possibly
+// match anything inside them. Returning SkipDir from fn prunes the
+// directory fn was called with (or, for a file, the rest of its
+// siblings) without aborting the walk; returning any other error aborts
+// the walk and is returned from GlobWalk.
+func GlobWalk(patterns []string, fn func(*FileAsset) error, opts ...GlobOption) error {
+	o := newGlobOptions(opts)
+	var lock sync.Mutex
+
+	var includes, excludes []glob.Pattern
+	var roots []string
+
+	for _, pattern := range patterns {
+		if strings.HasPrefix(pattern, "!") {
+			pat, err := compilePattern(pattern[1:], o)
+			if err != nil {
+				return err
+			}
+			excludes = append(excludes, pat)
+			continue
+		}
+
+		pat, err := compilePattern(pattern, o)
+		if err != nil {
+			return err
+		}
+		includes = append(includes, pat)
+		roots = append(roots, patternRoot(pattern))
+	}
+
+	root := commonRoot(roots)
+
+	visitor := func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		path = CleanPath(path)
+
+		if !o.matchHidden && path != root && isHidden(path) {
+			if info.IsDir() {
+				return SkipDir
+			}
+			return nil
+		}
+
+		if info.IsDir() && path != root && prunable(path, includes) {
+			return SkipDir
+		}
+
+		matched := false
+		for _, pat := range includes {
+			if pat.Match(path) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return nil
+		}
+		for _, pat := range excludes {
+			if pat.Match(path) {
+				return nil
+			}
+		}
+
+		asset := &FileAsset{FileInfo: info, Path: path, PatternRoot: root}
+		lock.Lock()
+		defer lock.Unlock()
+		return fn(asset)
+	}
+
+	if o.fs == OSFileSystem {
+		return walk.Walk(root, visitor)
+	}
+	return walkFSWith(o.fs, root, visitor)
+}
+
 // FileAsset contains file information and path from globbing.
 type FileAsset struct {
 	os.FileInfo
@@ -174,6 +267,11 @@ type FileAsset struct {
 	PatternRoot string
 }
 
+// CleanPath normalizes path to use forward slashes regardless of host OS.
+func CleanPath(path string) string {
+	return filepath.ToSlash(path)
+}
+
 // hasMeta determines if a path has special chars used to build a Regexp.
 func hasMeta(path string) bool {
 	return strings.IndexAny(path, "*?[{") >= 0
@@ -209,20 +307,102 @@ func patternRoot(s string) string {
 	if root == "" {
 		root = "."
 	}
-	return root
+	return CleanPath(root)
+}
+
+// commonRoot returns the deepest directory shared by every root in roots,
+// defaulting to "." when roots is empty or shares nothing.
+func commonRoot(roots []string) string {
+	if len(roots) == 0 {
+		return "."
+	}
+
+	common := strings.Split(roots[0], "/")
+	for _, r := range roots[1:] {
+		b := strings.Split(r, "/")
+		n := len(common)
+		if len(b) < n {
+			n = len(b)
+		}
+		i := 0
+		for i < n && common[i] == b[i] {
+			i++
+		}
+		common = common[:i]
+	}
+
+	if len(common) == 0 {
+		return "."
+	}
+	return strings.Join(common, "/")
 }
 
-// walkFiles walks a directory starting at root returning all directories and files
-// include those found in subdirectories.
-func walkFiles(root string) ([]*FileAsset, error) {
+// prunable reports whether path, a directory below the walk root, cannot
+// possibly contain anything matching one of includes. It's true only when
+// path has diverged from every pattern's literal prefix before reaching
+// the end of that prefix; once a path is inside a pattern's wildcard
+// region it may still match further down, so it's never prunable there.
+func prunable(path string, includes []glob.Pattern) bool {
+	if len(includes) == 0 {
+		return false
+	}
+
+	segments := strings.Split(path, "/")
+	for _, pat := range includes {
+		prefix := pat.Prefix()
+		n := len(prefix)
+		if len(segments) < n {
+			n = len(segments)
+		}
+		if equalSegments(segments[:n], prefix[:n]) {
+			return false
+		}
+	}
+	return true
+}
+
+// equalSegments reports whether a and b, of equal length, hold the same
+// path segments in order.
+func equalSegments(a, b []string) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// walkFiles walks a directory starting at root returning all directories
+// and files, including those found in subdirectories. It uses the
+// concurrent github.com/MichaelTJones/walk walker when fs is OSFileSystem,
+// and a plain recursive walk over fs otherwise. Dot-prefixed directories
+// (other than root itself) are skipped entirely unless matchHidden is true.
+func walkFiles(fs FileSystem, root string, matchHidden bool) ([]*FileAsset, error) {
+	if fs == OSFileSystem {
+		return walkOS(root, matchHidden)
+	}
+	return walkFS(fs, root, matchHidden)
+}
+
+// walkOS walks the OS filesystem starting at root using the concurrent
+// github.com/MichaelTJones/walk walker.
+func walkOS(root string, matchHidden bool) ([]*FileAsset, error) {
 	fileAssets := []*FileAsset{}
 	var lock sync.Mutex
 	visitor := func(path string, info os.FileInfo, err error) error {
-		if err == nil {
-			lock.Lock()
-			fileAssets = append(fileAssets, &FileAsset{FileInfo: info, Path: path})
-			lock.Unlock()
+		if err != nil {
+			return nil
 		}
+		path = CleanPath(path)
+		if !matchHidden && path != root && isHidden(path) {
+			if info.IsDir() {
+				return SkipDir
+			}
+			return nil
+		}
+		lock.Lock()
+		fileAssets = append(fileAssets, &FileAsset{FileInfo: info, Path: path})
+		lock.Unlock()
 		return nil
 	}
 	err := walk.Walk(root, visitor)
@@ -231,3 +411,70 @@ func walkFiles(root string) ([]*FileAsset, error) {
 	}
 	return fileAssets, nil
 }
+
+// walkFS walks fs starting at root, depth-first, returning every file and
+// directory beneath it (including root) as a FileAsset.
+func walkFS(fs FileSystem, root string, matchHidden bool) ([]*FileAsset, error) {
+	fileAssets := []*FileAsset{}
+	err := walkFSWith(fs, root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if !matchHidden && path != root && isHidden(path) {
+			if info.IsDir() {
+				return SkipDir
+			}
+			return nil
+		}
+		fileAssets = append(fileAssets, &FileAsset{FileInfo: info, Path: path})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fileAssets, nil
+}
+
+// walkFSWith walks fs starting at root, depth-first, calling visitor for
+// every entry under the same contract as filepath.Walk: returning SkipDir
+// for a directory skips it, and returning SkipDir for a file skips the
+// rest of its containing directory. Any other error aborts the walk and
+// is returned.
+func walkFSWith(fs FileSystem, root string, visitor filepath.WalkFunc) error {
+	err := walkFSStep(fs, root, visitor)
+	if err == filepath.SkipDir {
+		return nil
+	}
+	return err
+}
+
+// walkFSStep is walkFSWith's recursive step. It returns SkipDir to its
+// caller rather than swallowing it, so the loop over a directory's entries
+// can tell a skipped subdirectory (continue to the next sibling) from a
+// file that asked to skip the rest of its siblings (stop the loop).
+func walkFSStep(fs FileSystem, root string, visitor filepath.WalkFunc) error {
+	info, statErr := fs.Lstat(root)
+	if err := visitor(root, info, statErr); err != nil {
+		return err
+	}
+	if statErr != nil || info == nil || !info.IsDir() {
+		return nil
+	}
+
+	entries, err := fs.ReadDir(root)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		err := walkFSStep(fs, CleanPath(filepath.Join(root, entry.Name())), visitor)
+		if err == nil {
+			continue
+		}
+		if err == filepath.SkipDir && entry.IsDir() {
+			continue
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,468 @@
+// Package glob implements a doublestar-style path matcher.
+//
+// It walks a pattern and a candidate path segment-by-segment instead of
+// translating the pattern to a regexp, which gives it correct handling of
+// a few cases the naive regexp translation gets wrong: "**" recognized in
+// any segment (not just at the start, middle or end of the pattern),
+// brace groups that nest ({a,{b,c}}), POSIX bracket expressions
+// ([a-z], [!abc]), and backslash escaping of meta characters.
+package glob
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Pattern is a compiled glob pattern ready to be matched against one or
+// more candidate paths.
+type Pattern struct {
+	raw  string
+	alts [][]string // brace-expanded alternatives, each split into path segments
+	fold bool       // case-insensitive matching
+}
+
+// String returns the pattern text Pattern was compiled from.
+func (p Pattern) String() string {
+	return p.raw
+}
+
+// CompileOption configures optional Compile behavior.
+type CompileOption func(*compileOptions)
+
+type compileOptions struct {
+	caseInsensitive bool
+}
+
+// CaseInsensitive makes Compile produce a Pattern that matches regardless
+// of letter case.
+func CaseInsensitive() CompileOption {
+	return func(o *compileOptions) { o.caseInsensitive = true }
+}
+
+// Compile parses pattern and returns a Pattern that can be matched
+// repeatedly. It returns an error if pattern has an unbalanced brace
+// group, an unterminated or empty "[...]" class, a reversed "a-z" range,
+// or a dangling "\" escape.
+func Compile(pattern string, opts ...CompileOption) (Pattern, error) {
+	var o compileOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	expanded, err := expandBraces(pattern)
+	if err != nil {
+		return Pattern{}, err
+	}
+
+	alts := make([][]string, len(expanded))
+	for i, alt := range expanded {
+		segments := strings.Split(alt, "/")
+		for _, seg := range segments {
+			if seg == "**" {
+				continue
+			}
+			if err := validateSegment(seg); err != nil {
+				return Pattern{}, err
+			}
+		}
+		alts[i] = segments
+	}
+
+	return Pattern{raw: pattern, alts: alts, fold: o.caseInsensitive}, nil
+}
+
+// validateSegment reports a syntax error in a single pattern segment: a
+// "[" that's never closed, an empty or reversed-range character class, or
+// a "\" with nothing to escape.
+func validateSegment(segment string) error {
+	p := []rune(segment)
+	for i := 0; i < len(p); i++ {
+		switch p[i] {
+		case '\\':
+			if i+1 >= len(p) {
+				return fmt.Errorf("glob: dangling escape at end of pattern %q", segment)
+			}
+			i++
+
+		case '[':
+			end := indexClassEnd(p[i:])
+			if end < 0 {
+				return fmt.Errorf("glob: unterminated character class in %q", segment)
+			}
+			if err := validateClass(p[i+1 : i+end]); err != nil {
+				return err
+			}
+			i += end
+		}
+	}
+	return nil
+}
+
+// validateClass reports a syntax error in a bracket expression's content
+// (the runes between [ and ] with the brackets stripped).
+func validateClass(class []rune) error {
+	if len(class) > 0 && (class[0] == '!' || class[0] == '^') {
+		class = class[1:]
+	}
+	if len(class) == 0 {
+		return fmt.Errorf("glob: empty character class")
+	}
+
+	for i := 0; i < len(class); i++ {
+		ch := class[i]
+		if ch == '\\' && i+1 < len(class) {
+			i++
+			continue
+		}
+		if i+2 < len(class) && class[i+1] == '-' {
+			if ch > class[i+2] {
+				return fmt.Errorf("glob: invalid range %c-%c in character class", ch, class[i+2])
+			}
+			i += 2
+		}
+	}
+	return nil
+}
+
+// Match reports whether name, a slash separated path, matches p.
+func (p Pattern) Match(name string) bool {
+	segments := strings.Split(name, "/")
+	for _, alt := range p.alts {
+		if matchSegments(alt, segments, p.fold) {
+			return true
+		}
+	}
+	return false
+}
+
+// Prefix returns the literal, wildcard-free leading path segments shared
+// by every brace-expanded alternative in p. Callers driving a filesystem
+// walk can use it to prune: once a directory's path diverges from Prefix
+// before reaching its end, nothing under that directory can match p.
+func (p Pattern) Prefix() []string {
+	if len(p.alts) == 0 {
+		return nil
+	}
+
+	prefix := literalPrefix(p.alts[0])
+	for _, alt := range p.alts[1:] {
+		prefix = commonSegments(prefix, literalPrefix(alt))
+	}
+	return prefix
+}
+
+// literalPrefix returns the leading segments of segments that contain no
+// wildcard meta characters and aren't "**", with their \ escapes removed.
+func literalPrefix(segments []string) []string {
+	var prefix []string
+	for _, seg := range segments {
+		if seg == "**" || segmentHasMeta(seg) {
+			break
+		}
+		lit := make([]byte, 0, len(seg))
+		for i := 0; i < len(seg); i++ {
+			if seg[i] == '\\' && i+1 < len(seg) {
+				i++
+			}
+			lit = append(lit, seg[i])
+		}
+		prefix = append(prefix, string(lit))
+	}
+	return prefix
+}
+
+// segmentHasMeta reports whether segment contains an unescaped wildcard
+// meta character.
+func segmentHasMeta(segment string) bool {
+	for i := 0; i < len(segment); i++ {
+		switch segment[i] {
+		case '\\':
+			i++
+		case '*', '?', '[', '{':
+			return true
+		}
+	}
+	return false
+}
+
+// commonSegments returns the longest shared leading run of a and b.
+func commonSegments(a, b []string) []string {
+	n := len(a)
+	if len(b) < n {
+		n = len(b)
+	}
+	for i := 0; i < n; i++ {
+		if a[i] != b[i] {
+			return a[:i]
+		}
+	}
+	return a[:n]
+}
+
+// Match compiles pattern and reports whether name matches it. Callers
+// matching the same pattern against many names should call Compile once
+// and reuse the Pattern instead.
+func Match(pattern, name string) (bool, error) {
+	p, err := Compile(pattern)
+	if err != nil {
+		return false, err
+	}
+	return p.Match(name), nil
+}
+
+// matchSegments walks pattern and name one path segment at a time,
+// backtracking through "**" so it can stand for zero or more directories
+// no matter where it appears in pattern.
+func matchSegments(pattern, name []string, fold bool) bool {
+	if len(pattern) == 0 {
+		return len(name) == 0
+	}
+
+	if pattern[0] == "**" {
+		if matchSegments(pattern[1:], name, fold) {
+			return true
+		}
+		return len(name) > 0 && matchSegments(pattern, name[1:], fold)
+	}
+
+	if len(name) == 0 {
+		return false
+	}
+
+	if !matchSegment(pattern[0], name[0], fold) {
+		return false
+	}
+	return matchSegments(pattern[1:], name[1:], fold)
+}
+
+// matchSegment matches a single path segment against a single pattern
+// segment, honoring *, ?, [...] classes and \ escapes. pattern is assumed
+// to have already passed validateSegment, so malformed syntax ([, \) it
+// would have rejected is never seen here.
+func matchSegment(pattern, name string, fold bool) bool {
+	return matchRunes([]rune(pattern), []rune(name), fold)
+}
+
+func matchRunes(p, n []rune, fold bool) bool {
+	for len(p) > 0 {
+		switch p[0] {
+		case '*':
+			for len(p) > 0 && p[0] == '*' {
+				p = p[1:]
+			}
+			if len(p) == 0 {
+				return true
+			}
+			for i := 0; i <= len(n); i++ {
+				if matchRunes(p, n[i:], fold) {
+					return true
+				}
+			}
+			return false
+
+		case '?':
+			if len(n) == 0 {
+				return false
+			}
+			p, n = p[1:], n[1:]
+
+		case '[':
+			if len(n) == 0 {
+				return false
+			}
+			end := indexClassEnd(p)
+			if !matchClass(p[1:end], n[0], fold) {
+				return false
+			}
+			p, n = p[end+1:], n[1:]
+
+		case '\\':
+			if len(n) == 0 || !runeEqual(n[0], p[1], fold) {
+				return false
+			}
+			p, n = p[2:], n[1:]
+
+		default:
+			if len(n) == 0 || !runeEqual(n[0], p[0], fold) {
+				return false
+			}
+			p, n = p[1:], n[1:]
+		}
+	}
+	return len(n) == 0
+}
+
+// runeEqual reports whether a and b are the same rune, or the same letter
+// when fold is true.
+func runeEqual(a, b rune, fold bool) bool {
+	if a == b {
+		return true
+	}
+	return fold && unicode.ToLower(a) == unicode.ToLower(b)
+}
+
+// matchClass reports whether c is a member of the bracket expression
+// class (the runes between [ and ] with the brackets stripped). class is
+// assumed to have already passed validateClass.
+func matchClass(class []rune, c rune, fold bool) bool {
+	negate := false
+	if len(class) > 0 && (class[0] == '!' || class[0] == '^') {
+		negate = true
+		class = class[1:]
+	}
+
+	matched := false
+	for i := 0; i < len(class); i++ {
+		ch := class[i]
+		if ch == '\\' && i+1 < len(class) {
+			i++
+			if runeEqual(class[i], c, fold) {
+				matched = true
+			}
+			continue
+		}
+		if i+2 < len(class) && class[i+1] == '-' {
+			if inRange(c, ch, class[i+2], fold) {
+				matched = true
+			}
+			i += 2
+			continue
+		}
+		if runeEqual(ch, c, fold) {
+			matched = true
+		}
+	}
+
+	return matched != negate
+}
+
+// inRange reports whether c falls within [lo, hi], or within the range
+// folded to the same case as c when fold is true.
+func inRange(c, lo, hi rune, fold bool) bool {
+	if c >= lo && c <= hi {
+		return true
+	}
+	if !fold {
+		return false
+	}
+	lower, upper := unicode.ToLower(c), unicode.ToUpper(c)
+	return (lower >= unicode.ToLower(lo) && lower <= unicode.ToLower(hi)) ||
+		(upper >= unicode.ToUpper(lo) && upper <= unicode.ToUpper(hi))
+}
+
+// indexClassEnd returns the index of the ] that closes the bracket
+// expression starting at p[0], or -1 if it's unterminated. A ] appearing
+// immediately after [ or [! is treated as a literal member of the class,
+// matching common glob convention.
+func indexClassEnd(p []rune) int {
+	i := 1
+	if i < len(p) && (p[i] == '!' || p[i] == '^') {
+		i++
+	}
+	if i < len(p) && p[i] == ']' {
+		i++
+	}
+	for ; i < len(p); i++ {
+		if p[i] == '\\' {
+			i++
+			continue
+		}
+		if p[i] == ']' {
+			return i
+		}
+	}
+	return -1
+}
+
+// expandBraces expands the {a,b,...} groups in pattern, which may nest,
+// into the list of literal alternatives they represent. A pattern with no
+// brace group expands to itself.
+func expandBraces(pattern string) ([]string, error) {
+	start := indexUnescaped(pattern, '{')
+	if start < 0 {
+		return []string{pattern}, nil
+	}
+
+	end, err := matchingBrace(pattern, start)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := pattern[:start]
+	suffix := pattern[end+1:]
+	options := splitTopLevel(pattern[start+1:end], ',')
+	if len(options) < 2 {
+		return nil, fmt.Errorf("glob: brace group %q needs at least one comma", pattern[start:end+1])
+	}
+
+	var results []string
+	for _, opt := range options {
+		expanded, err := expandBraces(prefix + opt + suffix)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, expanded...)
+	}
+	return results, nil
+}
+
+// matchingBrace returns the index of the } that closes the { at
+// pattern[start], accounting for nested brace groups and \ escapes.
+func matchingBrace(pattern string, start int) (int, error) {
+	depth := 0
+	for i := start; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i, nil
+			}
+		}
+	}
+	return -1, fmt.Errorf("glob: unbalanced '{' in pattern %q", pattern)
+}
+
+// indexUnescaped returns the index of the first unescaped occurrence of b
+// in s, or -1 if there isn't one.
+func indexUnescaped(s string, b byte) int {
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' {
+			i++
+			continue
+		}
+		if s[i] == b {
+			return i
+		}
+	}
+	return -1
+}
+
+// splitTopLevel splits s on sep, ignoring occurrences of sep nested
+// inside a {} or [] group and those escaped with \.
+func splitTopLevel(s string, sep byte) []string {
+	var parts []string
+	depth := 0
+	last := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+		case sep:
+			if depth == 0 {
+				parts = append(parts, s[last:i])
+				last = i + 1
+			}
+		}
+	}
+	parts = append(parts, s[last:])
+	return parts
+}
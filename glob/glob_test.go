@@ -0,0 +1,106 @@
+package glob
+
+import "testing"
+
+func TestMatch(t *testing.T) {
+	cases := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"a/b/c.go", "a/b/c.go", true},
+		{"a/b/c.go", "a/b/d.go", false},
+		{"*.go", "main.go", true},
+		{"*.go", "a/main.go", false},
+		{"a/*/c.go", "a/b/c.go", true},
+		{"a/?.go", "a/b.go", true},
+		{"a/?.go", "a/bc.go", false},
+		{"**/*.go", "main.go", true},
+		{"**/*.go", "a/b/c.go", true},
+		{"a/**/c.go", "a/c.go", true},
+		{"a/**/c.go", "a/b/x/c.go", true},
+		{"a/**/c.go", "a/b/c.txt", false},
+		{"a/{b,c}/d.go", "a/b/d.go", true},
+		{"a/{b,c}/d.go", "a/c/d.go", true},
+		{"a/{b,c}/d.go", "a/e/d.go", false},
+		{"a/{b,{c,d}}.go", "a/d.go", true},
+		{"[a-c].go", "b.go", true},
+		{"[a-c].go", "d.go", false},
+		{"[!a-c].go", "d.go", true},
+		{"[!a-c].go", "a.go", false},
+		{`a\*.go`, "a*.go", true},
+		{`a\*.go`, "ab.go", false},
+	}
+
+	for _, c := range cases {
+		p, err := Compile(c.pattern)
+		if err != nil {
+			t.Errorf("Compile(%q) returned error: %v", c.pattern, err)
+			continue
+		}
+		if got := p.Match(c.name); got != c.want {
+			t.Errorf("Compile(%q).Match(%q) = %v, want %v", c.pattern, c.name, got, c.want)
+		}
+	}
+}
+
+func TestCaseInsensitive(t *testing.T) {
+	p, err := Compile("A/B.GO", CaseInsensitive())
+	if err != nil {
+		t.Fatalf("Compile returned error: %v", err)
+	}
+	if !p.Match("a/b.go") {
+		t.Error("Match(\"a/b.go\") = false, want true with CaseInsensitive")
+	}
+
+	p, err = Compile("A/B.GO")
+	if err != nil {
+		t.Fatalf("Compile returned error: %v", err)
+	}
+	if p.Match("a/b.go") {
+		t.Error("Match(\"a/b.go\") = true, want false without CaseInsensitive")
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	cases := []string{
+		"a[bc.go",   // unterminated class
+		"a[].go",    // leading ] is literal, so the class is unterminated
+		"a[z-a].go", // reversed range
+		`a\`,        // dangling escape
+		"a{b,c.go",  // unbalanced brace
+		"a{b}.go",   // brace group with no comma
+	}
+
+	for _, pattern := range cases {
+		if _, err := Compile(pattern); err == nil {
+			t.Errorf("Compile(%q) returned nil error, want error", pattern)
+		}
+	}
+}
+
+func TestMatchFunc(t *testing.T) {
+	ok, err := Match("a/*.go", "a/main.go")
+	if err != nil {
+		t.Fatalf("Match returned error: %v", err)
+	}
+	if !ok {
+		t.Error("Match(\"a/*.go\", \"a/main.go\") = false, want true")
+	}
+
+	if _, err := Match("a[bc.go", "a[bc.go"); err == nil {
+		t.Error("Match with malformed pattern returned nil error, want error")
+	}
+}
+
+func TestPrefix(t *testing.T) {
+	p, err := Compile("src/**/foo/*.go")
+	if err != nil {
+		t.Fatalf("Compile returned error: %v", err)
+	}
+	got := p.Prefix()
+	want := []string{"src"}
+	if len(got) != len(want) || got[0] != want[0] {
+		t.Errorf("Prefix() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,324 @@
+package gosu
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// memFileInfo is a minimal os.FileInfo for memFS entries.
+type memFileInfo struct {
+	name  string
+	isDir bool
+}
+
+func (fi memFileInfo) Name() string { return fi.name }
+func (fi memFileInfo) Size() int64  { return 0 }
+func (fi memFileInfo) Mode() os.FileMode {
+	if fi.isDir {
+		return os.ModeDir
+	}
+	return 0
+}
+func (fi memFileInfo) ModTime() time.Time { return time.Time{} }
+func (fi memFileInfo) IsDir() bool        { return fi.isDir }
+func (fi memFileInfo) Sys() interface{}   { return nil }
+
+// memFS is a FileSystem backed by an in-memory set of paths, letting Glob
+// be tested without touching disk.
+type memFS struct {
+	dirs map[string]bool // path ("." for root) -> isDir
+}
+
+func newMemFS(files ...string) *memFS {
+	fs := &memFS{dirs: map[string]bool{".": true}}
+	for _, f := range files {
+		fs.dirs[f] = false
+		for dir := f; ; {
+			i := strings.LastIndex(dir, "/")
+			if i < 0 {
+				break
+			}
+			dir = dir[:i]
+			fs.dirs[dir] = true
+		}
+	}
+	return fs
+}
+
+func (fs *memFS) Open(name string) (File, error) {
+	return nil, os.ErrInvalid
+}
+
+func (fs *memFS) Stat(name string) (os.FileInfo, error) {
+	return fs.Lstat(name)
+}
+
+func (fs *memFS) Lstat(name string) (os.FileInfo, error) {
+	isDir, ok := fs.dirs[name]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return memFileInfo{name: base(name), isDir: isDir}, nil
+}
+
+func (fs *memFS) ReadDir(name string) ([]os.FileInfo, error) {
+	var entries []os.FileInfo
+	for path, isDir := range fs.dirs {
+		if path == "." || dir(path) != name {
+			continue
+		}
+		entries = append(entries, memFileInfo{name: base(path), isDir: isDir})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+func base(path string) string {
+	if i := strings.LastIndex(path, "/"); i >= 0 {
+		return path[i+1:]
+	}
+	return path
+}
+
+func dir(path string) string {
+	if i := strings.LastIndex(path, "/"); i >= 0 {
+		return path[:i]
+	}
+	return "."
+}
+
+func TestGlobFS(t *testing.T) {
+	fs := newMemFS("a/b/c.go", "a/b/d.txt", "a/.git/config")
+
+	assets, _, err := GlobFS(fs, []string{"a/**/*.go"})
+	if err != nil {
+		t.Fatalf("GlobFS returned error: %v", err)
+	}
+	if !hasPath(assets, "a/b/c.go") {
+		t.Errorf("GlobFS result %v missing a/b/c.go", paths(assets))
+	}
+	if hasPath(assets, "a/b/d.txt") {
+		t.Errorf("GlobFS result %v unexpectedly matched a/b/d.txt", paths(assets))
+	}
+}
+
+func TestGlobWithMatchHidden(t *testing.T) {
+	fs := newMemFS("a/b/c.go", "a/.git/config")
+
+	assets, _, err := GlobWith([]string{"a/**/*"}, WithFS(fs), MatchHidden(false))
+	if err != nil {
+		t.Fatalf("GlobWith returned error: %v", err)
+	}
+	if hasPath(assets, "a/.git/config") {
+		t.Errorf("GlobWith with MatchHidden(false) matched a/.git/config, want pruned")
+	}
+
+	assets, _, err = GlobWith([]string{"a/**/*"}, WithFS(fs))
+	if err != nil {
+		t.Fatalf("GlobWith returned error: %v", err)
+	}
+	if !hasPath(assets, "a/.git/config") {
+		t.Errorf("GlobWith with default MatchHidden matched %v, want a/.git/config included", paths(assets))
+	}
+}
+
+func TestGlobWithCaseInsensitive(t *testing.T) {
+	fs := newMemFS("a/B.GO")
+
+	assets, _, err := GlobWith([]string{"a/b.go"}, WithFS(fs), CaseInsensitive())
+	if err != nil {
+		t.Fatalf("GlobWith returned error: %v", err)
+	}
+	if !hasPath(assets, "a/B.GO") {
+		t.Errorf("GlobWith with CaseInsensitive matched %v, want a/B.GO included", paths(assets))
+	}
+}
+
+func TestGlobWalkPruneAndSkip(t *testing.T) {
+	chdirTemp(t, "gosu-globwalk")
+
+	mustMkdirAll(t, filepath.Join("src", "pkg"))
+	mustMkdirAll(t, filepath.Join("vendor", "pkg"))
+	mustWriteFile(t, filepath.Join("src", "pkg", "a.go"))
+	mustWriteFile(t, filepath.Join("src", "pkg", "b.go"))
+	mustWriteFile(t, filepath.Join("vendor", "pkg", "c.go"))
+
+	var visited []string
+	err := GlobWalk([]string{"**/*.go"}, func(asset *FileAsset) error {
+		if strings.Contains(asset.Path, "vendor/") {
+			return SkipDir
+		}
+		visited = append(visited, asset.Path)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("GlobWalk returned error: %v", err)
+	}
+
+	want := "src/pkg/a.go"
+	found := false
+	for _, p := range visited {
+		if p == want {
+			found = true
+		}
+		if strings.Contains(p, "vendor/") {
+			t.Errorf("GlobWalk visited %q after SkipDir pruned vendor", p)
+		}
+	}
+	if !found {
+		t.Errorf("GlobWalk visited %v, want %q among them", visited, want)
+	}
+}
+
+func TestGlobWalkExclude(t *testing.T) {
+	chdirTemp(t, "gosu-globwalk-exclude")
+
+	mustWriteFile(t, "a.go")
+	mustWriteFile(t, "a_test.go")
+
+	var visited []string
+	err := GlobWalk([]string{"*.go", "!*_test.go"}, func(asset *FileAsset) error {
+		visited = append(visited, asset.Path)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("GlobWalk returned error: %v", err)
+	}
+
+	if hasString(visited, "a_test.go") {
+		t.Errorf("GlobWalk visited %v, want a_test.go excluded", visited)
+	}
+	if !hasString(visited, "a.go") {
+		t.Errorf("GlobWalk visited %v, want a.go included", visited)
+	}
+}
+
+// chdirTemp creates a temp dir, chdirs into it for the duration of the
+// test, and returns its path.
+func chdirTemp(t *testing.T, prefix string) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(cwd) })
+
+	return dir
+}
+
+// readDirCountingFS wraps a FileSystem, recording every path ReadDir is
+// called with so tests can assert a subtree was never descended into.
+type readDirCountingFS struct {
+	FileSystem
+	readDirs []string
+}
+
+func (fs *readDirCountingFS) ReadDir(name string) ([]os.FileInfo, error) {
+	fs.readDirs = append(fs.readDirs, name)
+	return fs.FileSystem.ReadDir(name)
+}
+
+func TestPrunableDivergingPrefix(t *testing.T) {
+	mem := newMemFS("src/foo/a.go", "src/bar/b.go", "src/baz/deep/c.go")
+	fs := &readDirCountingFS{FileSystem: mem}
+
+	var visited []string
+	err := GlobWalk([]string{"src/foo/**/*.go", "src/bar/**/*.go"}, func(asset *FileAsset) error {
+		visited = append(visited, asset.Path)
+		return nil
+	}, WithFS(fs))
+	if err != nil {
+		t.Fatalf("GlobWalk returned error: %v", err)
+	}
+
+	if !hasString(visited, "src/foo/a.go") || !hasString(visited, "src/bar/b.go") {
+		t.Errorf("GlobWalk visited %v, want src/foo/a.go and src/bar/b.go included", visited)
+	}
+	for _, p := range fs.readDirs {
+		if p == "src/baz" || p == "src/baz/deep" {
+			t.Errorf("GlobWalk called ReadDir(%q), want src/baz pruned since it diverges from every include prefix", p)
+		}
+	}
+}
+
+func TestGlobWalkSkipDirOnFileOverFS(t *testing.T) {
+	fs := newMemFS("a/x.go", "a/y.go", "a/z.go")
+
+	var visited []string
+	err := GlobWalk([]string{"a/*.go"}, func(asset *FileAsset) error {
+		visited = append(visited, asset.Path)
+		if asset.Path == "a/x.go" {
+			return SkipDir
+		}
+		return nil
+	}, WithFS(fs))
+	if err != nil {
+		t.Fatalf("GlobWalk returned error: %v", err)
+	}
+
+	want := []string{"a/x.go"}
+	if !reflect.DeepEqual(visited, want) {
+		t.Errorf("GlobWalk visited %v, want %v (SkipDir on a file should skip its siblings)", visited, want)
+	}
+}
+
+func TestCleanPath(t *testing.T) {
+	if got := CleanPath("a/b/c.go"); got != "a/b/c.go" {
+		t.Errorf("CleanPath(%q) = %q, want unchanged", "a/b/c.go", got)
+	}
+}
+
+func hasPath(assets []*FileAsset, path string) bool {
+	for _, a := range assets {
+		if a.Path == path {
+			return true
+		}
+	}
+	return false
+}
+
+func paths(assets []*FileAsset) []string {
+	var ps []string
+	for _, a := range assets {
+		ps = append(ps, a.Path)
+	}
+	return ps
+}
+
+func hasString(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func mustMkdirAll(t *testing.T, path string) {
+	t.Helper()
+	if err := os.MkdirAll(path, 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func mustWriteFile(t *testing.T, path string) {
+	t.Helper()
+	if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
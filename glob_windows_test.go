@@ -0,0 +1,22 @@
+package gosu
+
+import "testing"
+
+// TestCleanPathWindows covers the backslash-to-slash normalization
+// CleanPath exists for; filepath.ToSlash only rewrites the host OS
+// separator, so this matrix only exercises the backslash cases on
+// windows.
+func TestCleanPathWindows(t *testing.T) {
+	cases := []struct {
+		path string
+		want string
+	}{
+		{`a\b\c.go`, "a/b/c.go"},
+		{`a\b/c.go`, "a/b/c.go"},
+	}
+	for _, c := range cases {
+		if got := CleanPath(c.path); got != c.want {
+			t.Errorf("CleanPath(%q) = %q, want %q", c.path, got, c.want)
+		}
+	}
+}